@@ -0,0 +1,60 @@
+// The pcmds command bundles every command in this repository into a single
+// binary. Run it as "pcmds <command> [flags]", or symlink it to a command's
+// name and run it under that name.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/patrickod/pcmds/internal/podmetrics"
+	"github.com/patrickod/pcmds/internal/version"
+)
+
+var commands = map[string]func(args []string) error{
+	"pod-metrics": podmetrics.Main,
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "usage: pcmds <command> [flags]\n       pcmds version\n\ncommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	// invoked through a symlink named after the command
+	if run, ok := commands[filepath.Base(os.Args[0])]; ok {
+		if err := run(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "version", "-version", "--version":
+		fmt.Println(version.Get())
+		return
+	}
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "pcmds: unknown command %q\n\n", os.Args[1])
+		usage()
+	}
+	if err := run(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name     string        `yaml:"name" env:"TEST_NAME"`
+	Interval time.Duration `yaml:"interval" env:"TEST_INTERVAL"`
+	Tags     []string      `yaml:"tags" env:"TEST_TAGS"`
+	Count    int           `yaml:"count" env:"TEST_COUNT"`
+	Mode     string        `yaml:"mode" env:"TEST_MODE"`
+	Verbose  bool          `yaml:"verbose" env:"TEST_VERBOSE"`
+	Nested   struct {
+		Addr string `yaml:"addr" env:"TEST_ADDR"`
+	} `yaml:"nested"`
+}
+
+func defaults() testConfig {
+	return testConfig{Name: "default", Interval: time.Minute, Count: 1}
+}
+
+func (c *testConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Name, "name", c.Name, "")
+	fs.DurationVar(&c.Interval, "interval", c.Interval, "")
+	fs.IntVar(&c.Count, "count", c.Count, "")
+	// Func and BoolFunc values don't round-trip through String
+	fs.Func("mode", "", func(s string) error {
+		c.Mode = s
+		return nil
+	})
+	fs.BoolFunc("verbose", "", func(s string) error {
+		c.Verbose = s == "true"
+		return nil
+	})
+}
+
+func TestLoad(t *testing.T) {
+	const file = `
+name: file
+interval: 5m
+tags: [file-a, file-b]
+mode: file
+verbose: false
+nested:
+  addr: file:1
+`
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		args    []string
+		want    func(*testConfig)
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: func(*testConfig) {},
+		},
+		{
+			name: "file",
+			file: file,
+			want: func(c *testConfig) {
+				c.Name = "file"
+				c.Interval = 5 * time.Minute
+				c.Tags = []string{"file-a", "file-b"}
+				c.Mode = "file"
+				c.Nested.Addr = "file:1"
+			},
+		},
+		{
+			name: "env over file",
+			file: file,
+			env: map[string]string{
+				"TEST_NAME":     "env",
+				"TEST_INTERVAL": "90s",
+				"TEST_TAGS":     "env-a, env-b,,",
+				"TEST_COUNT":    "7",
+				"TEST_MODE":     "env",
+				"TEST_VERBOSE":  "true",
+				"TEST_ADDR":     "env:1",
+			},
+			want: func(c *testConfig) {
+				c.Name = "env"
+				c.Interval = 90 * time.Second
+				c.Tags = []string{"env-a", "env-b"}
+				c.Count = 7
+				c.Mode = "env"
+				c.Verbose = true
+				c.Nested.Addr = "env:1"
+			},
+		},
+		{
+			name: "flags over env",
+			file: file,
+			env: map[string]string{
+				"TEST_NAME":     "env",
+				"TEST_INTERVAL": "90s",
+				"TEST_MODE":     "env",
+			},
+			args: []string{"-name", "flag", "-interval", "2h", "-mode", "flag", "-verbose"},
+			want: func(c *testConfig) {
+				c.Name = "flag"
+				c.Interval = 2 * time.Hour
+				c.Tags = []string{"file-a", "file-b"}
+				c.Mode = "flag"
+				c.Verbose = true
+				c.Nested.Addr = "file:1"
+			},
+		},
+		{
+			name: "BoolFunc flag set false over env",
+			env:  map[string]string{"TEST_VERBOSE": "true"},
+			args: []string{"-verbose=false"},
+			want: func(c *testConfig) {},
+		},
+		{
+			name: "unset flags leave env",
+			env:  map[string]string{"TEST_COUNT": "3"},
+			args: []string{"-name", "flag"},
+			want: func(c *testConfig) {
+				c.Name = "flag"
+				c.Count = 3
+			},
+		},
+		{
+			name:    "bad duration",
+			env:     map[string]string{"TEST_INTERVAL": "soon"},
+			wantErr: true,
+		},
+		{
+			name:    "bad int",
+			env:     map[string]string{"TEST_COUNT": "many"},
+			wantErr: true,
+		},
+		{
+			name: "empty file",
+			file: "# nothing set\n",
+			want: func(*testConfig) {},
+		},
+		{
+			name:    "unknown key",
+			file:    "intreval: 5m\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown nested key",
+			file:    "nested:\n  adr: x\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad yaml",
+			file:    "name: [",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var path string
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got := defaults()
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			got.registerFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			err := Load(fs, tt.args, path, &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Load succeeded, want error; got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := defaults()
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadNotStructPointer(t *testing.T) {
+	var c testConfig
+	if err := Load(nil, nil, "", c); err == nil {
+		t.Error("Load of a non-pointer succeeded")
+	}
+}
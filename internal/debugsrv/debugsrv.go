@@ -0,0 +1,139 @@
+// Package debugsrv serves the tsweb debug pages (including pprof and
+// expvar) on their own listener, reachable only from localhost or the
+// tailnet, instead of on a command's main serving port.
+package debugsrv
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tsnet"
+	"tailscale.com/tsweb"
+)
+
+// Config configures the debug listener.
+type Config struct {
+	// Addr is the address to listen on. Under tsnet only its port is used,
+	// on the tailnet. Empty disables the debug server.
+	Addr string `yaml:"addr" env:"DEBUG_ADDR"`
+	// Allow lists the tailnet login names and ACL tags (tag:foo) allowed
+	// to reach the debug server under tsnet. Empty allows any tailnet peer.
+	Allow []string `yaml:"allow" env:"DEBUG_ALLOW"`
+}
+
+// RegisterFlags registers the debug server flags on fs, bound to c.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "debug-addr", c.Addr, "address for the debug server; under tsnet only the port is used (empty disables)")
+	fs.Var(allowFlag{&c.Allow}, "debug-allow", "comma-separated tailnet login names and tags allowed to reach the debug server (default: any tailnet peer)")
+}
+
+// allowFlag is the -debug-allow flag. Setting it replaces the list.
+type allowFlag struct {
+	list *[]string
+}
+
+func (f allowFlag) String() string {
+	if f.list == nil {
+		return ""
+	}
+	return strings.Join(*f.list, ",")
+}
+
+func (f allowFlag) Set(s string) error {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	*f.list = list
+	return nil
+}
+
+// Listen returns the debug listener and a handler serving the tsweb debug
+// pages. If srv is
+// non-nil the listener is on srv's tailnet and callers are checked against
+// c.Allow. It returns a nil listener when the debug server is disabled.
+func (c *Config) Listen(srv *tsnet.Server) (net.Listener, http.Handler, error) {
+	if c.Addr == "" {
+		return nil, nil, nil
+	}
+
+	mux := http.NewServeMux()
+	tsweb.Debugger(mux)
+
+	if srv == nil {
+		ln, err := net.Listen("tcp", c.Addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("debug server listening on %s", ln.Addr())
+		return ln, mux, nil
+	}
+
+	_, port, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("debug address: %w", err)
+	}
+	ln, err := srv.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, nil, err
+	}
+	lc, err := srv.LocalClient()
+	if err != nil {
+		ln.Close()
+		return nil, nil, err
+	}
+	log.Printf("debug server listening on tailnet port %s", port)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		who, err := lc.WhoIs(r.Context(), r.RemoteAddr)
+		if err != nil {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if !c.allowed(who) {
+			log.Printf("debug: denied %s from %s", r.URL.Path, peerName(who))
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+	return ln, h, nil
+}
+
+func (c *Config) allowed(who *apitype.WhoIsResponse) bool {
+	if len(c.Allow) == 0 {
+		return true
+	}
+	if who.Node != nil && who.Node.IsTagged() {
+		for _, tag := range who.Node.Tags {
+			if slices.Contains(c.Allow, tag) {
+				return true
+			}
+		}
+		return false
+	}
+	return who.UserProfile != nil && slices.Contains(c.Allow, who.UserProfile.LoginName)
+}
+
+// peerName names a WhoIs result for logs: the node name for tagged nodes,
+// otherwise the login name of the node's user.
+func peerName(who *apitype.WhoIsResponse) string {
+	if who.Node != nil && who.Node.IsTagged() {
+		return who.Node.ComputedName + " (" + strings.Join(who.Node.Tags, ",") + ")"
+	}
+	if who.UserProfile != nil {
+		return who.UserProfile.LoginName
+	}
+	if who.Node != nil {
+		return who.Node.ComputedName
+	}
+	return "unknown peer"
+}
@@ -0,0 +1,53 @@
+package debugsrv
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/patrickod/pcmds/internal/config"
+)
+
+func TestAllowFlagLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("allow: [file@example.com]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		env  string
+		want []string
+	}{
+		{"file", nil, "", []string{"file@example.com"}},
+		{"env", nil, "env@example.com", []string{"env@example.com"}},
+		{"flag", []string{"-debug-allow", "alice@example.com"}, "env@example.com", []string{"alice@example.com"}},
+		{"flag list", []string{"-debug-allow", "alice@example.com, tag:ops"}, "", []string{"alice@example.com", "tag:ops"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("DEBUG_ALLOW", tt.env)
+			}
+			var c Config
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			c.RegisterFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := config.Load(fs, tt.args, path, &c); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(c.Allow, tt.want) {
+				t.Errorf("Allow = %q, want %q", c.Allow, tt.want)
+			}
+			if got := fs.Lookup("debug-allow").Value.String(); got != strings.Join(tt.want, ",") {
+				t.Errorf("flag String() = %q, want %q", got, strings.Join(tt.want, ","))
+			}
+		})
+	}
+}
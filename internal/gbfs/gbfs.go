@@ -0,0 +1,123 @@
+// Package gbfs is a client for General Bikeshare Feed Specification feeds,
+// such as the one Baywheels publishes at https://gbfs.baywheels.com/gbfs/en.
+package gbfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Client fetches the feeds under a GBFS base URL.
+type Client struct {
+	http    *http.Client
+	baseURL string
+}
+
+// NewClient returns a Client for the feeds under baseURL, fetched with
+// client.
+func NewClient(client *http.Client, baseURL string) *Client {
+	return &Client{http: client, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// StationInformation is the static description of a station.
+type StationInformation struct {
+	Name                        string  `json:"name"`
+	ShortName                   string  `json:"short_name"`
+	StationId                   string  `json:"station_id"`
+	StationType                 string  `json:"station_type"`
+	Lat                         float64 `json:"lat"`
+	Lon                         float64 `json:"lon"`
+	ExternalId                  string  `json:"external_id"`
+	Capacity                    int     `json:"capacity"`
+	HasKiosk                    bool    `json:"has_kiosk"`
+	ElectricBikeSurchargeWaiver bool    `json:"electric_bike_surcharge_waiver"`
+}
+
+// StationStatus is the current state of a station.
+type StationStatus struct {
+	StationId           string `json:"station_id"`
+	IsInstalled         int    `json:"is_installed"`
+	IsRenting           int    `json:"is_renting"`
+	IsReturning         int    `json:"is_returning"`
+	LastReported        int    `json:"last_reported"`
+	BikesAvailable      int    `json:"num_bikes_available"`
+	BikesDisabled       int    `json:"num_bikes_disabled"`
+	DocksAvailable      int    `json:"num_docks_available"`
+	DocksDisabled       int    `json:"num_docks_disabled"`
+	EBikesAvailable     int    `json:"num_ebikes_available"`
+	ScootersAvailable   int    `json:"num_scooters_available"`
+	ScootersUnavailable int    `json:"num_scooters_unavailable"`
+}
+
+// BikeStatus is the current state of a bike that isn't docked at a
+// station.
+type BikeStatus struct {
+	BikeId     string  `json:"bike_id"`
+	IsDisabled int     `json:"is_disabled"`
+	IsReserved int     `json:"is_reserved"`
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+}
+
+// StationInformation fetches station_information.json.
+func (c *Client) StationInformation(ctx context.Context) ([]StationInformation, error) {
+	var data struct {
+		Stations []StationInformation `json:"stations"`
+	}
+	if err := c.fetch(ctx, "station_information", &data); err != nil {
+		return nil, err
+	}
+	return data.Stations, nil
+}
+
+// StationStatus fetches station_status.json.
+func (c *Client) StationStatus(ctx context.Context) ([]StationStatus, error) {
+	var data struct {
+		Stations []StationStatus `json:"stations"`
+	}
+	if err := c.fetch(ctx, "station_status", &data); err != nil {
+		return nil, err
+	}
+	return data.Stations, nil
+}
+
+// FreeBikeStatus fetches free_bike_status.json.
+func (c *Client) FreeBikeStatus(ctx context.Context) ([]BikeStatus, error) {
+	var data struct {
+		Bikes []BikeStatus `json:"bikes"`
+	}
+	if err := c.fetch(ctx, "free_bike_status", &data); err != nil {
+		return nil, err
+	}
+	return data.Bikes, nil
+}
+
+// fetch decodes the data object of the named feed into v.
+func (c *Client) fetch(ctx context.Context, feed string, v any) error {
+	url := fmt.Sprintf("%s/%s.json", c.baseURL, feed)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("gbfs: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("gbfs: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return fmt.Errorf("gbfs: fetching %s: %s", feed, resp.Status)
+	}
+
+	body := struct {
+		Data any `json:"data"`
+	}{Data: v}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("gbfs: decoding %s: %w", feed, err)
+	}
+	return nil
+}
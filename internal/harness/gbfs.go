@@ -0,0 +1,139 @@
+package harness
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+// A Station is a bike share station served by GBFS.
+type Station struct {
+	ID              string
+	Name            string
+	Capacity        int
+	BikesAvailable  int
+	BikesDisabled   int
+	EBikesAvailable int
+	DocksAvailable  int
+	DocksDisabled   int
+	Installed       bool
+	Renting         bool
+	Returning       bool
+	LastReported    time.Time
+}
+
+// A Bike is a free-floating bike served by GBFS.
+type Bike struct {
+	ID       string
+	Disabled bool
+	Reserved bool
+	Lat, Lon float64
+}
+
+// GBFS is a fake GBFS feed such as Baywheels'. Serve it on a Network and
+// point an exporter at the returned URL.
+type GBFS struct {
+	mu       sync.Mutex
+	stations []Station
+	bikes    []Bike
+	fail     map[string]int
+}
+
+// SetStations replaces the feed's stations.
+func (f *GBFS) SetStations(stations ...Station) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stations = stations
+}
+
+// SetBikes replaces the feed's free bikes.
+func (f *GBFS) SetBikes(bikes ...Bike) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bikes = bikes
+}
+
+// Fail makes the named feed, e.g. "station_status", respond with code. A
+// code of 0 restores it.
+func (f *GBFS) Fail(feed string, code int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail == nil {
+		f.fail = map[string]int{}
+	}
+	f.fail[feed] = code
+}
+
+func (f *GBFS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	feed := path.Base(r.URL.Path)
+	feed = feed[:len(feed)-len(path.Ext(feed))]
+	if code := f.fail[feed]; code != 0 {
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	var data any
+	switch feed {
+	case "station_information":
+		var stations []map[string]any
+		for _, s := range f.stations {
+			stations = append(stations, map[string]any{
+				"station_id": s.ID,
+				"name":       s.Name,
+				"capacity":   s.Capacity,
+			})
+		}
+		data = map[string]any{"stations": stations}
+	case "station_status":
+		var stations []map[string]any
+		for _, s := range f.stations {
+			stations = append(stations, map[string]any{
+				"station_id":           s.ID,
+				"num_bikes_available":  s.BikesAvailable,
+				"num_bikes_disabled":   s.BikesDisabled,
+				"num_ebikes_available": s.EBikesAvailable,
+				"num_docks_available":  s.DocksAvailable,
+				"num_docks_disabled":   s.DocksDisabled,
+				"is_installed":         b2i(s.Installed),
+				"is_renting":           b2i(s.Renting),
+				"is_returning":         b2i(s.Returning),
+				"last_reported":        s.LastReported.Unix(),
+			})
+		}
+		data = map[string]any{"stations": stations}
+	case "free_bike_status":
+		var bikes []map[string]any
+		for _, b := range f.bikes {
+			bikes = append(bikes, map[string]any{
+				"bike_id":     b.ID,
+				"is_disabled": b2i(b.Disabled),
+				"is_reserved": b2i(b.Reserved),
+				"lat":         b.Lat,
+				"lon":         b.Lon,
+			})
+		}
+		data = map[string]any{"bikes": bikes}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"last_updated": time.Now().Unix(),
+		"ttl":          60,
+		"data":         data,
+	})
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+// Package harness runs HTTP servers in-process for end-to-end tests. Servers and the fake upstreams they talk to are
+// attached to an in-memory Network, so no test touches a real socket or
+// the internet.
+package harness
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patrickod/pcmds/internal/rungroup"
+)
+
+// Network is a set of in-memory HTTP hosts.
+type Network struct {
+	tb testing.TB
+
+	mu        sync.Mutex
+	listeners map[string]*Listener
+}
+
+// NewNetwork returns an empty Network whose servers are shut down when tb
+// finishes.
+func NewNetwork(tb testing.TB) *Network {
+	return &Network{tb: tb, listeners: map[string]*Listener{}}
+}
+
+// Serve serves h as host, e.g. "gbfs.example", and returns the host's base
+// URL.
+func (n *Network) Serve(host string, h http.Handler) string {
+	n.tb.Helper()
+	addr := net.JoinHostPort(host, "80")
+	l := NewListener(addr)
+
+	n.mu.Lock()
+	if _, ok := n.listeners[addr]; ok {
+		n.mu.Unlock()
+		n.tb.Fatalf("harness: host %s already served", host)
+	}
+	n.listeners[addr] = l
+	n.mu.Unlock()
+
+	srv := &http.Server{Handler: h}
+	go srv.Serve(l)
+	n.tb.Cleanup(func() { srv.Close() })
+	return "http://" + host
+}
+
+// DialContext connects to a host served on n.
+func (n *Network) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	n.mu.Lock()
+	l, ok := n.listeners[addr]
+	n.mu.Unlock()
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: fmt.Errorf("harness: no host %s", addr)}
+	}
+	return l.DialContext(ctx)
+}
+
+// Client returns an HTTP client whose requests are routed to n's hosts.
+func (n *Network) Client() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{DialContext: n.DialContext},
+		Timeout:   10 * time.Second,
+	}
+}
+
+// Get fetches url through n and returns the status code and body.
+func (n *Network) Get(url string) (int, string) {
+	n.tb.Helper()
+	resp, err := n.Client().Get(url)
+	if err != nil {
+		n.tb.Fatalf("harness: GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		n.tb.Fatalf("harness: GET %s: %v", url, err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+// Group returns a rungroup.Group that is shut down, and waited for, when
+// tb finishes.
+func Group(tb testing.TB) *rungroup.Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := rungroup.WithContext(ctx, 5*time.Second)
+	tb.Cleanup(func() {
+		cancel()
+		if err := g.Wait(); err != nil {
+			tb.Errorf("harness: group: %v", err)
+		}
+	})
+	return g
+}
@@ -0,0 +1,50 @@
+package harness
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Shop is a fake Shopify product page, shaped like the Devolver Digital
+// store the COTL probe scrapes.
+type Shop struct {
+	mu      sync.Mutex
+	inStock bool
+	visits  int
+}
+
+// SetInStock sets whether the product can be added to the cart.
+func (s *Shop) SetInStock(inStock bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inStock = inStock
+}
+
+// Visits returns the number of times the product page has been fetched.
+func (s *Shop) Visits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.visits
+}
+
+func (s *Shop) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.visits++
+
+	disabled := ` disabled="disabled"`
+	if s.inStock {
+		disabled = ""
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><body>
+<form id="product-form" action="/cart/add" method="post">
+  <div class="product-submit">
+    <input type="submit" value="Add to cart"%s>
+  </div>
+</form>
+</body></html>
+`, disabled)
+}
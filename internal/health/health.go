@@ -0,0 +1,134 @@
+// Package health serves /healthz and /readyz endpoints backed by named
+// liveness and readiness checks.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Checker reports whether a component is healthy.
+type Checker func(context.Context) error
+
+type check struct {
+	name string
+	fn   Checker
+}
+
+// Health holds the liveness and readiness checks of a process.
+type Health struct {
+	mu    sync.Mutex
+	live  []check
+	ready []check
+}
+
+// New returns an empty Health. With no checks registered both endpoints
+// report ok.
+func New() *Health {
+	return &Health{}
+}
+
+// AddLiveness registers a check that must pass for /healthz to succeed.
+// Failing liveness means the process should be restarted.
+func (h *Health) AddLiveness(name string, fn Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.live = append(h.live, check{name, fn})
+}
+
+// AddReadiness registers a check that must pass for /readyz to succeed.
+// Failing readiness means the process is up but should not get traffic.
+func (h *Health) AddReadiness(name string, fn Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = append(h.ready, check{name, fn})
+}
+
+// Live runs the liveness checks and returns their combined error.
+func (h *Health) Live(ctx context.Context) error {
+	h.mu.Lock()
+	checks := h.live
+	h.mu.Unlock()
+
+	var errs []error
+	for _, c := range checks {
+		if err := c.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Register mounts /healthz and /readyz on mux.
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, false)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, true)
+	})
+}
+
+func (h *Health) serve(w http.ResponseWriter, r *http.Request, readiness bool) {
+	h.mu.Lock()
+	checks := h.live
+	if readiness {
+		// a process that isn't alive isn't ready either
+		checks = append(append([]check(nil), h.live...), h.ready...)
+	}
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var b strings.Builder
+	status := http.StatusOK
+	for _, c := range checks {
+		if err := c.fn(ctx); err != nil {
+			status = http.StatusServiceUnavailable
+			fmt.Fprintf(&b, "%s: %v\n", c.name, err)
+		} else {
+			fmt.Fprintf(&b, "%s: ok\n", c.name)
+		}
+	}
+	if status == http.StatusOK {
+		b.WriteString("ok\n")
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	io.WriteString(w, b.String())
+}
+
+// Timestamp records the last time an operation succeeded, such as a scrape
+// of an upstream. The zero value has never been marked.
+type Timestamp struct {
+	unixNano atomic.Int64
+}
+
+// Mark records the current time.
+func (t *Timestamp) Mark() {
+	t.unixNano.Store(time.Now().UnixNano())
+}
+
+// Fresh returns a Checker that fails unless t was marked within maxAge.
+func (t *Timestamp) Fresh(maxAge time.Duration) Checker {
+	return func(context.Context) error {
+		n := t.unixNano.Load()
+		if n == 0 {
+			return errors.New("never succeeded")
+		}
+		if age := time.Since(time.Unix(0, n)); age > maxAge {
+			return fmt.Errorf("last success %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+// Package httpclient builds outbound HTTP clients with bounded timeouts,
+// retries with backoff for idempotent requests, per-host rate limiting and a
+// common User-Agent.
+package httpclient
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// UserAgent is sent on every request that doesn't set its own.
+const UserAgent = "pcmds (+https://github.com/patrickod/pcmds)"
+
+// Options configures a client. The zero value gets the defaults noted on
+// each field.
+type Options struct {
+	// Timeout bounds how long a single attempt waits for response
+	// headers. The client's overall deadline allows Timeout per attempt
+	// plus the longest backoff and rate limit wait between attempts; see
+	// budget. Default 30s.
+	Timeout time.Duration
+	// Retries is the number of additional attempts made after a network
+	// error, 429 or 5xx response. Default 0.
+	Retries int
+	// Backoff is the base delay between retries, doubled on every attempt
+	// and jittered. A Retry-After longer than the attempt's backoff isn't
+	// waited out; the response is returned instead. Default 1s.
+	Backoff time.Duration
+	// RateLimit caps requests per second to any single host; 0 disables
+	// limiting.
+	RateLimit float64
+	// UserAgent overrides the package UserAgent.
+	UserAgent string
+}
+
+// New returns an http.Client configured by opts.
+func New(opts Options) *http.Client {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = UserAgent
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.ResponseHeaderTimeout = opts.Timeout
+	return &http.Client{
+		Timeout: opts.budget(),
+		Transport: &transport{
+			opts:     opts,
+			base:     base,
+			limiters: map[string]*rate.Limiter{},
+		},
+	}
+}
+
+// budget is the overall deadline for a request: every attempt, the most
+// each backoff can be, and one rate limit interval per attempt. Waiting
+// behind other requests to the same host isn't budgeted; a limiter wait
+// that can't finish before the deadline fails the request.
+func (o Options) budget() time.Duration {
+	attempts := time.Duration(o.Retries + 1)
+	d := o.Timeout * attempts
+	for attempt := 0; attempt < o.Retries; attempt++ {
+		d += o.Backoff << attempt
+	}
+	if o.RateLimit > 0 {
+		d += time.Duration(float64(time.Second)/o.RateLimit) * attempts
+	}
+	return d
+}
+
+type transport struct {
+	opts Options
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func (t *transport) limiter(host string) *rate.Limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l, ok := t.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(t.opts.RateLimit), 1)
+		t.limiters[host] = l
+	}
+	return l
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request, and retries
+	// replace the body.
+	req = req.Clone(req.Context())
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", t.opts.UserAgent)
+	}
+
+	retries := t.opts.Retries
+	if !replayable(req) {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if t.opts.RateLimit > 0 {
+			if err := t.limiter(req.URL.Host).Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= retries || !retryable(resp, err) {
+			return resp, err
+		}
+
+		delay, ok := t.backoff(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// replayable reports whether req is safe to send more than once.
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// backoff returns the delay before retrying after attempt. It reports false
+// when the server's Retry-After asks for longer than the attempt's backoff,
+// which the client's deadline doesn't allow for.
+func (t *transport) backoff(attempt int, resp *http.Response) (time.Duration, bool) {
+	d := t.opts.Backoff << attempt
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return after, after <= d
+		}
+	}
+	return d/2 + rand.N(d/2+1), true
+}
+
+// retryAfter parses a Retry-After value, either delay-seconds or an
+// HTTP-date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, secs >= 0
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(time.Until(t), 0), true
+}
@@ -0,0 +1,93 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	c := New(Options{Timeout: 10 * time.Second, Retries: 2, Backoff: time.Second, RateLimit: 1})
+	// 3 attempts of 10s, backoffs of 1s and 2s, and 1s of rate limit per
+	// attempt
+	if want := 36 * time.Second; c.Timeout != want {
+		t.Errorf("Timeout = %s, want %s", c.Timeout, want)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		wantCalls  int32
+		wantStatus int
+	}{
+		{"within backoff", "0", 3, http.StatusOK},
+		{"beyond backoff", "60", 1, http.StatusServiceUnavailable},
+		{"date in the past", "Mon, 02 Jan 2006 15:04:05 GMT", 3, http.StatusOK},
+		{"date beyond backoff", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 1, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) < 3 {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer srv.Close()
+
+			c := New(Options{Timeout: time.Second, Retries: 2, Backoff: 10 * time.Millisecond})
+			start := time.Now()
+			resp, err := c.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus || calls.Load() != tt.wantCalls {
+				t.Errorf("status %d after %d calls, want %d after %d", resp.StatusCode, calls.Load(), tt.wantStatus, tt.wantCalls)
+			}
+			if d := time.Since(start); d > c.Timeout {
+				t.Errorf("took %s, longer than the client's %s budget", d, c.Timeout)
+			}
+		})
+	}
+}
+
+func TestRetryLeavesRequestAlone(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, strings.NewReader("query"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "caller")
+	body := req.Body
+
+	// call the transport directly; http.Client copies requests that have
+	// a deadline
+	c := New(Options{Timeout: time.Second, Retries: 1, Backoff: time.Millisecond})
+	resp, err := c.Transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("status %d after %d calls, want 200 after 2", resp.StatusCode, calls.Load())
+	}
+	if req.Body != body {
+		t.Error("retry replaced the caller's request body")
+	}
+}
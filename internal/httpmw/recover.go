@@ -0,0 +1,49 @@
+// Package httpmw holds HTTP server middleware.
+package httpmw
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Recoverer turns handler panics into 500 responses.
+type Recoverer struct {
+	panics prometheus.Counter
+}
+
+// NewRecoverer returns a Recoverer that counts panics in a metric
+// registered on reg.
+func NewRecoverer(reg prometheus.Registerer) *Recoverer {
+	rc := &Recoverer{
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pcmds_http_panics_total",
+			Help: "Number of HTTP handler panics recovered",
+		}),
+	}
+	reg.MustRegister(rc.panics)
+	return rc
+}
+
+// Wrap returns h with panic recovery. A panic is logged with the request
+// and stack trace, counted, and answered with a 500.
+func (rc *Recoverer) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				// deliberate abort; let net/http handle it
+				panic(err)
+			}
+			rc.panics.Inc()
+			log.Printf("panic serving %s %s for %s: %v\n%s", r.Method, r.URL.RequestURI(), r.RemoteAddr, err, debug.Stack())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
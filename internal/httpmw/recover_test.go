@@ -0,0 +1,55 @@
+package httpmw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecoverer(t *testing.T) {
+	rc := NewRecoverer(prometheus.NewRegistry())
+	h := rc.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET / = %d, want 200", rec.Code)
+	}
+	if got := testutil.ToFloat64(rc.panics); got != 0 {
+		t.Errorf("panics = %v after a clean request, want 0", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("GET /panic = %d, want 500", rec.Code)
+	}
+	if got := testutil.ToFloat64(rc.panics); got != 1 {
+		t.Errorf("panics = %v, want 1", got)
+	}
+}
+
+func TestRecovererAbortHandler(t *testing.T) {
+	rc := NewRecoverer(prometheus.NewRegistry())
+	h := rc.Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler re-raised", err)
+		}
+		if got := testutil.ToFloat64(rc.panics); got != 0 {
+			t.Errorf("panics = %v after an abort, want 0", got)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// post sends body to url and fails on any non-2xx response.
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return post(ctx, client, url, "application/json", b, nil)
+}
+
+type ntfy struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func newNtfy(client *http.Client, url, tokenFile string) (*ntfy, error) {
+	s := &ntfy{client: client, url: url}
+	if tokenFile != "" {
+		b, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ntfy token: %w", err)
+		}
+		s.token = strings.TrimSpace(string(b))
+	}
+	return s, nil
+}
+
+func (s *ntfy) Name() string { return "ntfy" }
+
+func (s *ntfy) Send(ctx context.Context, m Message) error {
+	h := http.Header{}
+	h.Set("Title", m.Title)
+	if m.URL != "" {
+		h.Set("Click", m.URL)
+	}
+	if len(m.Tags) > 0 {
+		h.Set("Tags", strings.Join(m.Tags, ","))
+	}
+	if s.token != "" {
+		h.Set("Authorization", "Bearer "+s.token)
+	}
+	return post(ctx, s.client, s.url, "text/plain; charset=utf-8", []byte(m.Body), h)
+}
+
+type slack struct {
+	client *http.Client
+	url    string
+}
+
+func (s *slack) Name() string { return "slack" }
+
+func (s *slack) Send(ctx context.Context, m Message) error {
+	text := "*" + m.Title + "*\n" + m.Body
+	if m.URL != "" {
+		text += "\n" + m.URL
+	}
+	return postJSON(ctx, s.client, s.url, map[string]string{"text": text})
+}
+
+type discord struct {
+	client *http.Client
+	url    string
+}
+
+func (s *discord) Name() string { return "discord" }
+
+func (s *discord) Send(ctx context.Context, m Message) error {
+	content := "**" + m.Title + "**\n" + m.Body
+	if m.URL != "" {
+		content += "\n" + m.URL
+	}
+	return postJSON(ctx, s.client, s.url, map[string]string{"content": content})
+}
+
+type webhook struct {
+	client *http.Client
+	url    string
+}
+
+func (s *webhook) Name() string { return "webhook" }
+
+func (s *webhook) Send(ctx context.Context, m Message) error {
+	return postJSON(ctx, s.client, s.url, m)
+}
@@ -0,0 +1,220 @@
+// Package notify delivers alerts to ntfy, Slack and Discord webhooks, email
+// over SMTP and generic JSON webhooks. A Notifier fans a Message out to every configured sink,
+// retrying failed deliveries and suppressing repeats of the same alert
+// within a cooldown.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A Message is one alert.
+type Message struct {
+	Title string   `json:"title"`
+	Body  string   `json:"body"`
+	URL   string   `json:"url,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// A Sender delivers messages to one sink.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, m Message) error
+}
+
+// Config selects the sinks to deliver to. Sinks left empty are disabled.
+type Config struct {
+	// NtfyURL is the ntfy topic URL, e.g. https://ntfy.sh/my-topic.
+	NtfyURL string `yaml:"ntfy_url" env:"NOTIFY_NTFY_URL"`
+	// NtfyTokenFile holds an ntfy access token for protected topics.
+	NtfyTokenFile string `yaml:"ntfy_token_file" env:"NOTIFY_NTFY_TOKEN_FILE"`
+	// SlackURL is a Slack incoming webhook URL.
+	SlackURL string `yaml:"slack_url" env:"NOTIFY_SLACK_URL"`
+	// DiscordURL is a Discord webhook URL.
+	DiscordURL string `yaml:"discord_url" env:"NOTIFY_DISCORD_URL"`
+	// WebhookURL receives each Message POSTed as JSON.
+	WebhookURL string     `yaml:"webhook_url" env:"NOTIFY_WEBHOOK_URL"`
+	SMTP       SMTPConfig `yaml:"smtp"`
+
+	// Cooldown is the minimum time between two deliveries of alerts with
+	// the same key. Zero disables the cooldown; negative uses the default
+	// of 30m.
+	Cooldown time.Duration `yaml:"cooldown" env:"NOTIFY_COOLDOWN"`
+	// Retries is the number of extra attempts per sink after a failed
+	// delivery. Zero disables retries; negative uses the default of 3.
+	Retries int `yaml:"retries" env:"NOTIFY_RETRIES"`
+}
+
+// DefaultConfig returns a Config with no sinks and the default cooldown
+// and retries.
+func DefaultConfig() Config {
+	return Config{Cooldown: 30 * time.Minute, Retries: 3}
+}
+
+// Notifier fans messages out to a set of senders.
+type Notifier struct {
+	senders  []Sender
+	cooldown time.Duration
+	retries  int
+
+	mu   sync.Mutex
+	last map[string]time.Time
+
+	sent *prometheus.CounterVec
+}
+
+// New returns a Notifier for the sinks in cfg, sending HTTP requests with
+// client. Delivery metrics are registered on reg. A Notifier with no sinks
+// configured accepts and drops every message.
+func New(cfg Config, client *http.Client, reg prometheus.Registerer) (*Notifier, error) {
+	def := DefaultConfig()
+	if cfg.Cooldown < 0 {
+		cfg.Cooldown = def.Cooldown
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = def.Retries
+	}
+	n := &Notifier{
+		cooldown: cfg.Cooldown,
+		retries:  cfg.Retries,
+		last:     map[string]time.Time{},
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pcmds_notifications_total",
+			Help: "Number of notification deliveries by sink and result",
+		}, []string{"sink", "result"}),
+	}
+	reg.MustRegister(n.sent)
+
+	if cfg.NtfyURL != "" {
+		s, err := newNtfy(client, cfg.NtfyURL, cfg.NtfyTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		n.senders = append(n.senders, s)
+	}
+	if cfg.SlackURL != "" {
+		n.senders = append(n.senders, &slack{client: client, url: cfg.SlackURL})
+	}
+	if cfg.DiscordURL != "" {
+		n.senders = append(n.senders, &discord{client: client, url: cfg.DiscordURL})
+	}
+	if cfg.WebhookURL != "" {
+		n.senders = append(n.senders, &webhook{client: client, url: cfg.WebhookURL})
+	}
+	if cfg.SMTP.Addr != "" {
+		s, err := newSMTP(cfg.SMTP)
+		if err != nil {
+			return nil, err
+		}
+		n.senders = append(n.senders, s)
+	}
+	return n, nil
+}
+
+// Notify sends m to every sink unless an alert with the same key was
+// delivered within the cooldown. The cooldown only starts once at least
+// one sink has delivered m. It returns the delivery errors of the sinks
+// that still failed after retrying, so callers can try again later.
+func (n *Notifier) Notify(ctx context.Context, key string, m Message) error {
+	if len(n.senders) == 0 || n.cooling(key) {
+		return nil
+	}
+	log.Printf("notify: %s", m.Title)
+
+	errs := make([]error, len(n.senders))
+	var wg sync.WaitGroup
+	for i, s := range n.senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = n.send(ctx, s, m)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			n.mu.Lock()
+			n.last[key] = time.Now()
+			n.mu.Unlock()
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cooling reports whether an alert with key was delivered within the
+// cooldown.
+func (n *Notifier) cooling(key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	t, ok := n.last[key]
+	return ok && time.Since(t) < n.cooldown
+}
+
+// sendTimeout bounds a single delivery attempt to one sink.
+const sendTimeout = 30 * time.Second
+
+func (n *Notifier) send(ctx context.Context, s Sender, m Message) error {
+	for attempt := 0; ; attempt++ {
+		sctx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := s.Send(sctx, m)
+		cancel()
+		if err == nil {
+			n.sent.WithLabelValues(s.Name(), "success").Inc()
+			return nil
+		}
+		if attempt >= n.retries || ctx.Err() != nil {
+			n.sent.WithLabelValues(s.Name(), "failure").Inc()
+			return fmt.Errorf("notify %s: %w", s.Name(), err)
+		}
+		select {
+		case <-ctx.Done():
+			n.sent.WithLabelValues(s.Name(), "failure").Inc()
+			return fmt.Errorf("notify %s: %w", s.Name(), ctx.Err())
+		case <-time.After(time.Second << attempt):
+		}
+	}
+}
+
+// A Template renders a Message from text/template sources, so that
+// commands can let their config override alert wording.
+type Template struct {
+	Title string `yaml:"title"`
+	Body  string `yaml:"body"`
+}
+
+// Render executes t's title and body with data.
+func (t Template) Render(data any) (Message, error) {
+	title, err := execute(t.Title, data)
+	if err != nil {
+		return Message{}, fmt.Errorf("rendering title: %w", err)
+	}
+	body, err := execute(t.Body, data)
+	if err != nil {
+		return Message{}, fmt.Errorf("rendering body: %w", err)
+	}
+	return Message{Title: title, Body: body}, nil
+}
+
+func execute(text string, data any) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
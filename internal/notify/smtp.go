@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTPConfig configures email delivery. Addr is the submission server as
+// host:port; authentication is only attempted when Username is set.
+type SMTPConfig struct {
+	Addr         string   `yaml:"addr" env:"NOTIFY_SMTP_ADDR"`
+	From         string   `yaml:"from" env:"NOTIFY_SMTP_FROM"`
+	To           []string `yaml:"to" env:"NOTIFY_SMTP_TO"`
+	Username     string   `yaml:"username" env:"NOTIFY_SMTP_USERNAME"`
+	PasswordFile string   `yaml:"password_file" env:"NOTIFY_SMTP_PASSWORD_FILE"`
+}
+
+type smtpSender struct {
+	cfg  SMTPConfig
+	auth smtp.Auth
+}
+
+func newSMTP(cfg SMTPConfig) (*smtpSender, error) {
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("notify: smtp needs from and to addresses")
+	}
+	s := &smtpSender{cfg: cfg}
+	if cfg.Username != "" {
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("notify: smtp addr: %w", err)
+		}
+		var password string
+		if cfg.PasswordFile != "" {
+			b, err := os.ReadFile(cfg.PasswordFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading smtp password: %w", err)
+			}
+			password = strings.TrimSpace(string(b))
+		}
+		s.auth = smtp.PlainAuth("", cfg.Username, password, host)
+	}
+	return s, nil
+}
+
+func (s *smtpSender) Name() string { return "smtp" }
+
+// Send delivers m as a plain-text email. The connection's deadline follows
+// ctx, so a server that stalls mid-conversation can't block Send past it.
+func (s *smtpSender) Send(ctx context.Context, m Message) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	host, _, err := net.SplitHostPort(s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp server doesn't support AUTH")
+		}
+		if err := c.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range s.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, s.message(m)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s *smtpSender) message(m Message) string {
+	body := m.Body
+	if m.URL != "" {
+		body += "\n\n" + m.URL
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	// header values must stay on one line
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.Join(strings.Fields(m.Title), " "))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSMTPStalledServer(t *testing.T) {
+	// a server that accepts connections and never sends its greeting
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	s, err := newSMTP(SMTPConfig{Addr: ln.Addr().String(), From: "a@example.com", To: []string{"b@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Send(ctx, Message{Title: "t", Body: "b"}) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Send to a stalled server succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send blocked past its context")
+	}
+}
@@ -0,0 +1,138 @@
+package podmetrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/patrickod/pcmds/internal/gbfs"
+	"github.com/patrickod/pcmds/internal/notify"
+)
+
+// BaywheelsAlert fires when a station drops below any of its minimums.
+// Zero minimums are ignored.
+type BaywheelsAlert struct {
+	StationID string `yaml:"station_id"`
+	MinBikes  int    `yaml:"min_bikes"`
+	MinEBikes int    `yaml:"min_ebikes"`
+	MinDocks  int    `yaml:"min_docks"`
+}
+
+// AlertTemplates hold the wording of pod-metrics' alerts.
+type AlertTemplates struct {
+	COTLInStock notify.Template `yaml:"cotl_in_stock"`
+	StationLow  notify.Template `yaml:"station_low"`
+}
+
+func defaultAlertTemplates() AlertTemplates {
+	return AlertTemplates{
+		COTLInStock: notify.Template{
+			Title: "Cult of the Lamb pillow in stock",
+			Body:  "The Cult of the Lamb pillow is back in stock.",
+		},
+		StationLow: notify.Template{
+			Title: "Baywheels station {{.Station}} low on {{.Kind}}",
+			Body:  "{{.Available}} {{.Kind}} available, below the minimum of {{.Min}}.",
+		},
+	}
+}
+
+// stationLowData is the data the station_low template is executed with.
+func stationLowData(station, kind string, available, min int) map[string]any {
+	return map[string]any{
+		"Station":   station,
+		"Kind":      kind,
+		"Available": available,
+		"Min":       min,
+	}
+}
+
+// inStockData is the data the cotl_in_stock template is executed with.
+func inStockData(url string) map[string]any {
+	return map[string]any{"URL": url}
+}
+
+// Validate renders each template with example data, so that syntax errors
+// and unknown fields fail at startup rather than when an alert fires.
+func (t AlertTemplates) Validate() error {
+	if _, err := t.COTLInStock.Render(inStockData(COTLCushionURL)); err != nil {
+		return fmt.Errorf("templates.cotl_in_stock: %w", err)
+	}
+	if _, err := t.StationLow.Render(stationLowData("example", "bikes", 0, 1)); err != nil {
+		return fmt.Errorf("templates.station_low: %w", err)
+	}
+	return nil
+}
+
+// stationAlerts checks station status against the configured thresholds
+// and notifies when a station crosses below one of them.
+type stationAlerts struct {
+	n     *notify.Notifier
+	tmpl  notify.Template
+	rules map[string]BaywheelsAlert
+	// alerted holds the "<station>/<kind>" keys that are below their
+	// minimum and have been notified.
+	alerted map[string]bool
+}
+
+func newStationAlerts(n *notify.Notifier, tmpl notify.Template, rules []BaywheelsAlert) *stationAlerts {
+	a := &stationAlerts{
+		n:       n,
+		tmpl:    tmpl,
+		rules:   map[string]BaywheelsAlert{},
+		alerted: map[string]bool{},
+	}
+	for _, r := range rules {
+		a.rules[r.StationID] = r
+	}
+	return a
+}
+
+func (a *stationAlerts) check(ctx context.Context, stations []gbfs.StationStatus) {
+	for _, st := range stations {
+		r, ok := a.rules[st.StationId]
+		if !ok {
+			continue
+		}
+		a.threshold(ctx, st.StationId, "bikes", st.BikesAvailable, r.MinBikes)
+		a.threshold(ctx, st.StationId, "e-bikes", st.EBikesAvailable, r.MinEBikes)
+		a.threshold(ctx, st.StationId, "docks", st.DocksAvailable, r.MinDocks)
+	}
+}
+
+func (a *stationAlerts) threshold(ctx context.Context, station, kind string, available, min int) {
+	if min == 0 {
+		return
+	}
+	key := station + "/" + kind
+	if available >= min {
+		delete(a.alerted, key)
+		return
+	}
+	if a.alerted[key] {
+		return
+	}
+	m, err := a.tmpl.Render(stationLowData(station, kind, available, min))
+	if err != nil {
+		log.Printf("station alert: %v", err)
+		return
+	}
+	m.Tags = []string{"bike"}
+	// left unset on failure so the next sample tries again
+	if err := a.n.Notify(ctx, "baywheels/"+key, m); err != nil {
+		log.Printf("station alert: %v", err)
+		return
+	}
+	a.alerted[key] = true
+}
+
+// notifyInStock alerts that the COTL pillow has come back into stock.
+func notifyInStock(ctx context.Context, n *notify.Notifier, tmpl notify.Template, url string) error {
+	m, err := tmpl.Render(inStockData(url))
+	if err != nil {
+		return fmt.Errorf("cotl alert: %w", err)
+	}
+	m.URL = url
+	m.Tags = []string{"tada"}
+	return n.Notify(ctx, "cotl/in-stock", m)
+}
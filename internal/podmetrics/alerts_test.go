@@ -0,0 +1,160 @@
+package podmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/patrickod/pcmds/internal/gbfs"
+	"github.com/patrickod/pcmds/internal/harness"
+	"github.com/patrickod/pcmds/internal/notify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hookRecorder is a webhook sink that records the messages POSTed to it.
+type hookRecorder struct {
+	mu     sync.Mutex
+	msgs   []notify.Message
+	status int // if non-zero, the status to fail requests with
+}
+
+// fail makes the sink fail every request with status, or recover if
+// status is 0.
+func (h *hookRecorder) fail(status int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status = status
+}
+
+func (h *hookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	status := h.status
+	h.mu.Unlock()
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	var m notify.Message
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.msgs = append(h.msgs, m)
+}
+
+func (h *hookRecorder) messages() []notify.Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]notify.Message(nil), h.msgs...)
+}
+
+func TestCOTLInStockAlert(t *testing.T) {
+	net := harness.NewNetwork(t)
+	shop := &harness.Shop{}
+	hook := &hookRecorder{}
+	url := net.Serve("shop.test", shop) + "/products/cult-of-the-lamb-pillow"
+
+	reg := prometheus.NewRegistry()
+	n, err := notify.New(notify.Config{
+		WebhookURL: net.Serve("hook.test", hook),
+		// no cooldown, so that only the transition logic suppresses
+		// repeats
+	}, net.Client(), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe := newProbe(net.Client(), NewMetrics(reg), n, defaultAlertTemplates().COTLInStock, url)
+
+	steps := []struct {
+		inStock bool
+		alerts  int
+	}{
+		{false, 0},
+		{false, 0},
+		{true, 1},
+		{true, 1},
+		{false, 1},
+		{true, 2},
+	}
+	for i, step := range steps {
+		shop.SetInStock(step.inStock)
+		if err := probe.check(context.Background()); err != nil {
+			t.Fatalf("check %d: %v", i, err)
+		}
+		if got := shop.Visits(); got != i+1 {
+			t.Fatalf("after check %d: shop visited %d times, want %d", i, got, i+1)
+		}
+		if got := len(hook.messages()); got != step.alerts {
+			t.Fatalf("after check %d (in stock %v): %d alerts, want %d", i, step.inStock, got, step.alerts)
+		}
+	}
+
+	m := hook.messages()[0]
+	if m.Title != "Cult of the Lamb pillow in stock" || m.URL != url {
+		t.Errorf("alert = %+v", m)
+	}
+}
+
+func TestAlertsRetryAfterSinkFailure(t *testing.T) {
+	net := harness.NewNetwork(t)
+	shop := &harness.Shop{}
+	shop.SetInStock(true)
+	hook := &hookRecorder{}
+	hook.fail(http.StatusInternalServerError)
+
+	reg := prometheus.NewRegistry()
+	n, err := notify.New(notify.Config{
+		WebhookURL: net.Serve("hook.test", hook),
+		Cooldown:   -1, // the default, which must not start on failure
+	}, net.Client(), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := defaultAlertTemplates()
+	probe := newProbe(net.Client(), NewMetrics(reg), n, tmpl.COTLInStock, net.Serve("shop.test", shop))
+	alerts := newStationAlerts(n, tmpl.StationLow, []BaywheelsAlert{{StationID: "s1", MinBikes: 2}})
+	stations := []gbfs.StationStatus{{StationId: "s1", BikesAvailable: 1}}
+
+	ctx := context.Background()
+	check := func(want int) {
+		t.Helper()
+		if err := probe.check(ctx); err != nil {
+			t.Fatal(err)
+		}
+		alerts.check(ctx, stations)
+		if got := len(hook.messages()); got != want {
+			t.Fatalf("%d alerts delivered, want %d", got, want)
+		}
+	}
+
+	check(0)
+	check(0)
+	hook.fail(0)
+	check(2)
+	check(2)
+}
+
+func TestAlertTemplatesValidate(t *testing.T) {
+	if err := defaultAlertTemplates().Validate(); err != nil {
+		t.Errorf("default templates: %v", err)
+	}
+	for _, tmpl := range []notify.Template{
+		{Title: "{{.URL", Body: "unclosed action"},
+		{Title: "ok", Body: "{{.Stock}}"},
+	} {
+		bad := defaultAlertTemplates()
+		bad.COTLInStock = tmpl
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate accepted %+v", tmpl)
+		}
+	}
+	bad := defaultAlertTemplates()
+	bad.StationLow.Title = "{{.Staton}}"
+	if err := bad.Validate(); err == nil {
+		t.Error("Validate accepted a misspelled station_low field")
+	}
+}
@@ -0,0 +1,473 @@
+// Package podmetrics implements the pod-metrics exporter, which samples
+// Baywheels station and bike status and the Cult of the Lamb pillow stock.
+package podmetrics
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gocolly/colly"
+	"github.com/patrickod/pcmds/internal/config"
+	"github.com/patrickod/pcmds/internal/debugsrv"
+	"github.com/patrickod/pcmds/internal/gbfs"
+	"github.com/patrickod/pcmds/internal/health"
+	"github.com/patrickod/pcmds/internal/httpclient"
+	"github.com/patrickod/pcmds/internal/httpmw"
+	"github.com/patrickod/pcmds/internal/notify"
+	"github.com/patrickod/pcmds/internal/promutil"
+	"github.com/patrickod/pcmds/internal/rungroup"
+	"github.com/patrickod/pcmds/internal/scrape"
+	"github.com/patrickod/pcmds/internal/systemd"
+	"github.com/patrickod/pcmds/internal/tsnetutil"
+	"github.com/patrickod/pcmds/internal/version"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const ListenPort = 8080
+const BaywheelsURL = "https://gbfs.baywheels.com/gbfs/en"
+const COTLCushionURL = "https://merch.devolverdigital.com/products/cult-of-the-lamb-pillow"
+
+type PODMetrics struct {
+	// Cult of the Lamb pillow stock metrics
+	cotl_pillow_last_check prometheus.Gauge
+	cotl_pillow_in_stock   prometheus.Gauge
+
+	// Baywheels bike metrics
+	baywheels_bike_disabled prometheus.GaugeVec
+	baywheels_bike_reserved prometheus.GaugeVec
+	// Baywheels station metrics
+	baywheels_station_bikes_available  prometheus.GaugeVec
+	baywheels_station_bikes_disabled   prometheus.GaugeVec
+	baywheels_station_capacity         prometheus.GaugeVec
+	baywheels_station_docks_available  prometheus.GaugeVec
+	baywheels_station_docks_disabled   prometheus.GaugeVec
+	baywheels_station_ebikes_available prometheus.GaugeVec
+	baywheels_station_is_installed     prometheus.GaugeVec
+	baywheels_station_is_renting       prometheus.GaugeVec
+	baywheels_station_is_returning     prometheus.GaugeVec
+	baywheels_station_last_report      prometheus.GaugeVec
+}
+
+// Config configures pod-metrics. DefaultConfig returns the defaults.
+type Config struct {
+	ListenAddr        string           `yaml:"listen_addr" env:"POD_METRICS_LISTEN_ADDR"`
+	BaywheelsURL      string           `yaml:"baywheels_url" env:"POD_METRICS_BAYWHEELS_URL"`
+	BaywheelsInterval time.Duration    `yaml:"baywheels_interval" env:"POD_METRICS_BAYWHEELS_INTERVAL"`
+	COTLURL           string           `yaml:"cotl_url" env:"POD_METRICS_COTL_URL"`
+	COTLInterval      time.Duration    `yaml:"cotl_interval" env:"POD_METRICS_COTL_INTERVAL"`
+	Tsnet             tsnetutil.Config `yaml:"tsnet"`
+	Debug             debugsrv.Config  `yaml:"debug"`
+	Notify            notify.Config    `yaml:"notify"`
+	BaywheelsAlerts   []BaywheelsAlert `yaml:"baywheels_alerts"`
+	Templates         AlertTemplates   `yaml:"templates"`
+}
+
+// DefaultConfig returns the configuration pod-metrics runs with when
+// nothing overrides it.
+func DefaultConfig() Config {
+	return Config{
+		ListenAddr:        fmt.Sprintf(":%d", ListenPort),
+		BaywheelsURL:      BaywheelsURL,
+		BaywheelsInterval: 60 * time.Second,
+		COTLURL:           COTLCushionURL,
+		COTLInterval:      60 * time.Second * 5,
+		Tsnet:             tsnetutil.Config{Hostname: "baywheels-exporter"},
+		Debug:             debugsrv.Config{Addr: "localhost:8081"},
+		Notify:            notify.DefaultConfig(),
+		Templates:         defaultAlertTemplates(),
+	}
+}
+
+func (m *PODMetrics) Reset() {
+	m.baywheels_station_capacity.Reset()
+	m.baywheels_bike_reserved.Reset()
+	m.baywheels_bike_disabled.Reset()
+	m.baywheels_station_last_report.Reset()
+	m.baywheels_station_is_returning.Reset()
+	m.baywheels_station_is_renting.Reset()
+	m.baywheels_station_is_installed.Reset()
+	m.baywheels_station_bikes_available.Reset()
+	m.baywheels_station_bikes_disabled.Reset()
+	m.baywheels_station_docks_available.Reset()
+	m.baywheels_station_docks_disabled.Reset()
+	m.baywheels_station_ebikes_available.Reset()
+}
+
+func NewMetrics(reg prometheus.Registerer) *PODMetrics {
+	m := &PODMetrics{
+		baywheels_station_capacity: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_capacity",
+			Help: "Bike capacity of the station.",
+		},
+			[]string{"station_id", "name"},
+		),
+
+		baywheels_bike_disabled: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_bike_disabled",
+			Help: "Bike is_disabled status",
+		},
+			[]string{"bike_id"},
+		),
+		baywheels_bike_reserved: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_bike_reserved",
+			Help: "Bike is_reserved status",
+		},
+			[]string{"bike_id"},
+		),
+		baywheels_station_last_report: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_last_report",
+			Help: "Station status report last check-in timestamp",
+		},
+			[]string{"station_id"},
+		),
+		baywheels_station_is_returning: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_is_returning",
+			Help: "Station is_returning status",
+		},
+			[]string{"station_id"},
+		),
+		baywheels_station_is_renting: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_is_renting",
+			Help: "Station is_renting status",
+		},
+			[]string{"station_id"},
+		),
+		baywheels_station_is_installed: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_is_installed",
+			Help: "Station is_installed status",
+		},
+			[]string{"station_id"},
+		),
+		baywheels_station_bikes_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_bikes_available",
+			Help: "Number of bikes available at the station",
+		},
+			[]string{"station_id"},
+		),
+		baywheels_station_bikes_disabled: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_bikes_disabled",
+			Help: "Number of bikes disabled at the station",
+		},
+			[]string{"station_id"},
+		),
+		baywheels_station_docks_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_docks_available",
+			Help: "Number of docks available at the station",
+		},
+			[]string{"station_id"},
+		),
+		baywheels_station_docks_disabled: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_docks_disabled",
+			Help: "Number of docks disabled at the station",
+		},
+			[]string{"station_id"},
+		),
+		baywheels_station_ebikes_available: *prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "baywheels_station_ebikes_available",
+			Help: "Number of ebikes available at the station",
+		},
+			[]string{"station_id"},
+		),
+		cotl_pillow_in_stock: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cotl_pillow_in_stock",
+			Help: "Whether the Cult of the Lamb Pillow is in stock",
+		}),
+		cotl_pillow_last_check: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cotl_pillow_last_check",
+			Help: "The last time the Cult of the Lamb Pillow was checked for stock",
+		}),
+	}
+	reg.MustRegister(m.baywheels_station_capacity)
+	reg.MustRegister(m.baywheels_bike_disabled)
+	reg.MustRegister(m.baywheels_bike_reserved)
+	reg.MustRegister(m.baywheels_station_last_report)
+	reg.MustRegister(m.baywheels_station_is_returning)
+	reg.MustRegister(m.baywheels_station_is_renting)
+	reg.MustRegister(m.baywheels_station_is_installed)
+	reg.MustRegister(m.baywheels_station_bikes_available)
+	reg.MustRegister(m.baywheels_station_bikes_disabled)
+	reg.MustRegister(m.baywheels_station_docks_available)
+	reg.MustRegister(m.baywheels_station_docks_disabled)
+	reg.MustRegister(m.baywheels_station_ebikes_available)
+
+	reg.MustRegister(m.cotl_pillow_in_stock)
+	reg.MustRegister(m.cotl_pillow_last_check)
+
+	return m
+}
+
+func sampleStationInformation(ctx context.Context, client *gbfs.Client, metrics *PODMetrics) error {
+	stations, err := client.StationInformation(ctx)
+	if err != nil {
+		return fmt.Errorf("error sampling station information: %w", err)
+	}
+	for _, station := range stations {
+		metrics.baywheels_station_capacity.With(prometheus.Labels{"station_id": station.StationId, "name": station.Name}).Set(float64(station.Capacity))
+	}
+	return nil
+}
+
+func sampleBikeInformation(ctx context.Context, client *gbfs.Client, metrics *PODMetrics) error {
+	bikes, err := client.FreeBikeStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("error sampling bike status: %w", err)
+	}
+	for _, bike := range bikes {
+		metrics.baywheels_bike_disabled.With(prometheus.Labels{"bike_id": bike.BikeId}).Set(float64(bike.IsDisabled))
+		metrics.baywheels_bike_reserved.With(prometheus.Labels{"bike_id": bike.BikeId}).Set(float64(bike.IsReserved))
+	}
+	return nil
+}
+
+func sampleStationStatus(ctx context.Context, client *gbfs.Client, metrics *PODMetrics) ([]gbfs.StationStatus, error) {
+	stations, err := client.StationStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error sampling station status: %w", err)
+	}
+
+	for _, station := range stations {
+		// station stats
+		metrics.baywheels_station_last_report.With(prometheus.Labels{"station_id": station.StationId}).Set(float64(station.LastReported))
+		metrics.baywheels_station_is_returning.With(prometheus.Labels{"station_id": station.StationId}).Set(float64(station.IsReturning))
+		metrics.baywheels_station_is_renting.With(prometheus.Labels{"station_id": station.StationId}).Set(float64(station.IsRenting))
+		metrics.baywheels_station_is_installed.With(prometheus.Labels{"station_id": station.StationId}).Set(float64(station.IsInstalled))
+
+		// pedal bike stats
+		metrics.baywheels_station_bikes_available.With(prometheus.Labels{"station_id": station.StationId}).Set(float64(station.BikesAvailable))
+		metrics.baywheels_station_bikes_disabled.With(prometheus.Labels{"station_id": station.StationId}).Set(float64(station.BikesDisabled))
+
+		// dock stats
+		metrics.baywheels_station_docks_available.With(prometheus.Labels{"station_id": station.StationId}).Set(float64(station.DocksAvailable))
+		metrics.baywheels_station_docks_disabled.With(prometheus.Labels{"station_id": station.StationId}).Set(float64(station.DocksDisabled))
+
+		// e-bike stats
+		metrics.baywheels_station_ebikes_available.With(prometheus.Labels{"station_id": station.StationId}).Set(float64(station.EBikesAvailable))
+	}
+	return stations, nil
+}
+
+func sampleBaywheelsMetrics(ctx context.Context, client *gbfs.Client, metrics *PODMetrics, alerts *stationAlerts) error {
+	metrics.Reset()
+	stations, statusErr := sampleStationStatus(ctx, client, metrics)
+	if statusErr == nil {
+		alerts.check(ctx, stations)
+	}
+	return errors.Join(
+		sampleStationInformation(ctx, client, metrics),
+		statusErr,
+		sampleBikeInformation(ctx, client, metrics),
+	)
+}
+
+type cotlProbe struct {
+	c       *colly.Collector
+	url     string
+	metrics *PODMetrics
+
+	notifier *notify.Notifier
+	tmpl     notify.Template
+	inStock  bool
+	// alerted is whether the current in-stock spell has been notified.
+	alerted bool
+}
+
+func newProbe(client *http.Client, metrics *PODMetrics, n *notify.Notifier, tmpl notify.Template, url string) *cotlProbe {
+	p := &cotlProbe{url: url, metrics: metrics, notifier: n, tmpl: tmpl}
+	// the same page is checked every interval, so revisits must be allowed
+	c := colly.NewCollector(colly.UserAgent(httpclient.UserAgent), colly.AllowURLRevisit())
+	c.WithTransport(client.Transport)
+	c.SetRequestTimeout(client.Timeout)
+	c.OnHTML("#product-form .product-submit", func(e *colly.HTMLElement) {
+		disabled := e.ChildAttr("input", "disabled")
+		// non-empty disabled attribute on submit indicates out of stock
+		if len(disabled) > 0 {
+			log.Printf("Cult of the Lamb Pillow out of stock")
+			metrics.cotl_pillow_in_stock.Set(0)
+			p.inStock = false
+		} else {
+			metrics.cotl_pillow_in_stock.Set(1)
+			log.Printf("Cult of the Lamb Pillow IS IN STOCK")
+			p.inStock = true
+		}
+	})
+	p.c = c
+	return p
+}
+
+func (p *cotlProbe) check(ctx context.Context) error {
+	log.Printf("Visiting %s", p.url)
+	if err := p.c.Visit(p.url); err != nil {
+		return fmt.Errorf("error scraping COTL pillow stock: %w", err)
+	}
+	p.metrics.cotl_pillow_last_check.SetToCurrentTime()
+	if !p.inStock {
+		p.alerted = false
+	} else if !p.alerted {
+		// left unset on failure so the next check tries again
+		if err := notifyInStock(ctx, p.notifier, p.tmpl, p.url); err != nil {
+			log.Printf("%v", err)
+		} else {
+			p.alerted = true
+		}
+	}
+	return nil
+}
+
+// Exporter is pod-metrics without its listeners: the metrics, scrape
+// targets and HTTP handler. Main serves one on the network; tests can drive
+// one in-process.
+type Exporter struct {
+	// Registry holds every metric the exporter serves.
+	Registry *prometheus.Registry
+	// Health holds the exporter's liveness and readiness checks.
+	Health *health.Health
+
+	scraper   *scrape.Scraper
+	recoverer *httpmw.Recoverer
+}
+
+// New builds an Exporter from cfg that fetches upstream data with client.
+func New(cfg Config, client *http.Client) (*Exporter, error) {
+	reg := promutil.NewRegistry(true)
+	metrics := NewMetrics(reg)
+	version.RegisterMetric(reg)
+
+	if err := cfg.Templates.Validate(); err != nil {
+		return nil, err
+	}
+	notifier, err := notify.New(cfg.Notify, client, reg)
+	if err != nil {
+		return nil, err
+	}
+	probe := newProbe(client, metrics, notifier, cfg.Templates.COTLInStock, cfg.COTLURL)
+	alerts := newStationAlerts(notifier, cfg.Templates.StationLow, cfg.BaywheelsAlerts)
+
+	baywheels := gbfs.NewClient(client, cfg.BaywheelsURL)
+
+	sc := scrape.New(reg)
+	err = sc.Add(&scrape.Target{
+		Name:     "cotl",
+		Interval: cfg.COTLInterval,
+		Collect: func(ctx context.Context) error {
+			return probe.check(ctx)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = sc.Add(&scrape.Target{
+		Name:     "baywheels",
+		Interval: cfg.BaywheelsInterval,
+		Collect: func(ctx context.Context) error {
+			return sampleBaywheelsMetrics(ctx, baywheels, metrics, alerts)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Exporter{
+		Registry:  reg,
+		Health:    health.New(),
+		scraper:   sc,
+		recoverer: httpmw.NewRecoverer(reg),
+	}
+	sc.AddReadiness(e.Health)
+	sc.AddLiveness(e.Health)
+	return e, nil
+}
+
+// Start runs the scrape loops in g.
+func (e *Exporter) Start(g *rungroup.Group) {
+	e.scraper.Start(g)
+}
+
+// Handler returns the exporter's HTTP handler, serving health, version and
+// metrics endpoints.
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	e.Health.Register(mux)
+	mux.Handle("/version", version.Handler())
+	promutil.Mount(mux, e.Registry)
+	return e.recoverer.Wrap(mux)
+}
+
+// Main runs pod-metrics with the given command-line arguments, not
+// including the program name.
+func Main(args []string) error {
+	fs := flag.NewFlagSet("pod-metrics", flag.ExitOnError)
+	cfg := DefaultConfig()
+	configPath := fs.String("config", "", "path to a YAML config file")
+	showVersion := fs.Bool("version", false, "print the version and exit")
+	cfg.Tsnet.RegisterFlags(fs)
+	cfg.Debug.RegisterFlags(fs)
+	fs.Parse(args)
+	if *showVersion {
+		fmt.Println(version.Get())
+		return nil
+	}
+	if err := config.Load(fs, args, *configPath, &cfg); err != nil {
+		return err
+	}
+
+	client := httpclient.New(httpclient.Options{
+		Timeout:   10 * time.Second,
+		Retries:   2,
+		RateLimit: 1,
+	})
+	e, err := New(cfg, client)
+	if err != nil {
+		return err
+	}
+
+	ln, srv, err := cfg.Tsnet.Listen(cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+	rln, redirect, err := cfg.Tsnet.ListenHTTPRedirect(srv)
+	if err != nil {
+		return err
+	}
+	dln, debug, err := cfg.Debug.Listen(srv)
+	if err != nil {
+		return err
+	}
+
+	g := rungroup.New(10 * time.Second)
+	if srv != nil {
+		g.DeferClose(srv)
+		g.Go(func(ctx context.Context) error {
+			tsnetutil.WatchAuth(ctx, srv)
+			return nil
+		})
+		e.Health.AddReadiness("tailscale", tsnetutil.HealthCheck(srv))
+	}
+	e.Start(g)
+
+	g.Serve(&http.Server{Handler: e.Handler()}, ln)
+	if rln != nil {
+		g.Serve(&http.Server{Handler: e.recoverer.Wrap(redirect)}, rln)
+	}
+	if dln != nil {
+		g.Serve(&http.Server{Handler: e.recoverer.Wrap(debug)}, dln)
+	}
+
+	sd := systemd.New()
+	g.DeferClose(sd)
+	g.Defer(func(context.Context) error {
+		sd.Stopping()
+		return nil
+	})
+	g.Go(func(ctx context.Context) error {
+		sd.Watchdog(ctx, e.Health.Live)
+		return nil
+	})
+	sd.Ready()
+
+	return g.Wait()
+}
@@ -0,0 +1,135 @@
+package podmetrics
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patrickod/pcmds/internal/harness"
+)
+
+func TestExporter(t *testing.T) {
+	net := harness.NewNetwork(t)
+	feed := &harness.GBFS{}
+	feed.SetStations(
+		harness.Station{ID: "s1", Name: "Market St", Capacity: 20, BikesAvailable: 1, EBikesAvailable: 3, DocksAvailable: 16, Installed: true, Renting: true, Returning: true},
+		harness.Station{ID: "s2", Name: "Valencia St", Capacity: 15, BikesAvailable: 9, DocksAvailable: 6, Installed: true},
+	)
+	feed.SetBikes(harness.Bike{ID: "b1", Disabled: true})
+	shop := &harness.Shop{}
+	shop.SetInStock(true)
+	hook := &hookRecorder{}
+
+	cfg := DefaultConfig()
+	cfg.BaywheelsURL = net.Serve("gbfs.test", feed) + "/gbfs/en"
+	cfg.COTLURL = net.Serve("shop.test", shop) + "/products/cult-of-the-lamb-pillow"
+	cfg.Notify.WebhookURL = net.Serve("hook.test", hook)
+	cfg.BaywheelsAlerts = []BaywheelsAlert{{StationID: "s1", MinBikes: 2}}
+
+	e, err := New(cfg, net.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Start(harness.Group(t))
+	url := net.Serve("pod-metrics.test", e.Handler())
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		code, body := net.Get(url + "/readyz")
+		if code == 200 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("/readyz = %d:\n%s", code, body)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	code, body := net.Get(url + "/metrics")
+	if code != 200 {
+		t.Fatalf("/metrics = %d", code)
+	}
+	for _, want := range []string{
+		`baywheels_station_capacity{name="Market St",station_id="s1"} 20`,
+		`baywheels_station_bikes_available{station_id="s1"} 1`,
+		`baywheels_station_ebikes_available{station_id="s1"} 3`,
+		`baywheels_station_docks_available{station_id="s2"} 6`,
+		`baywheels_station_is_renting{station_id="s2"} 0`,
+		`baywheels_bike_disabled{bike_id="b1"} 1`,
+		`cotl_pillow_in_stock 1`,
+		`pcmds_collect_success{target="baywheels"} 1`,
+		`pcmds_collect_success{target="cotl"} 1`,
+		`pcmds_notifications_total{result="success",sink="webhook"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+
+	titles := map[string]bool{}
+	for _, m := range hook.messages() {
+		titles[m.Title] = true
+	}
+	for _, want := range []string{
+		"Cult of the Lamb pillow in stock",
+		"Baywheels station s1 low on bikes",
+	} {
+		if !titles[want] {
+			t.Errorf("no %q alert; got %v", want, titles)
+		}
+	}
+}
+
+func TestExporterUpstreamFailure(t *testing.T) {
+	net := harness.NewNetwork(t)
+	feed := &harness.GBFS{}
+	feed.SetStations(harness.Station{ID: "s1", Name: "Market St", Capacity: 20, BikesAvailable: 1})
+	feed.Fail("station_status", http.StatusServiceUnavailable)
+	hook := &hookRecorder{}
+
+	cfg := DefaultConfig()
+	cfg.BaywheelsURL = net.Serve("gbfs.test", feed) + "/gbfs/en"
+	cfg.BaywheelsInterval = 50 * time.Millisecond
+	cfg.COTLURL = net.Serve("shop.test", &harness.Shop{}) + "/products/cult-of-the-lamb-pillow"
+	cfg.Notify.WebhookURL = net.Serve("hook.test", hook)
+	cfg.BaywheelsAlerts = []BaywheelsAlert{{StationID: "s1", MinBikes: 2}}
+
+	e, err := New(cfg, net.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Start(harness.Group(t))
+	url := net.Serve("pod-metrics.test", e.Handler())
+
+	waitFor(t, func() bool {
+		_, body := net.Get(url + "/metrics")
+		return strings.Contains(body, `pcmds_collect_success{target="baywheels"} 0`)
+	})
+	code, body := net.Get(url + "/readyz")
+	if code != http.StatusServiceUnavailable || !strings.Contains(body, "baywheels: never succeeded") {
+		t.Errorf("/readyz with station_status failing = %d:\n%s", code, body)
+	}
+	if msgs := hook.messages(); len(msgs) != 0 {
+		t.Errorf("alerts sent with station_status failing: %+v", msgs)
+	}
+
+	feed.Fail("station_status", 0)
+	waitFor(t, func() bool {
+		code, _ := net.Get(url + "/readyz")
+		return code == http.StatusOK
+	})
+	waitFor(t, func() bool { return len(hook.messages()) == 1 })
+}
+
+// waitFor polls cond until it holds, failing the test after 10s.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,33 @@
+// Package promutil creates Prometheus registries and serves them on
+// /metrics.
+package promutil
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewRegistry returns an empty per-process registry. If runtime is set the
+// Go runtime and process collectors are registered on it too.
+func NewRegistry(runtime bool) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	if runtime {
+		reg.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+	return reg
+}
+
+// Mount serves the metrics in reg at /metrics on mux.
+func Mount(mux *http.ServeMux, reg *prometheus.Registry) {
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{
+		ErrorLog: log.Default(),
+		Registry: reg,
+	}))
+}
@@ -0,0 +1,122 @@
+// Package rungroup runs the long-lived parts of a command under a single
+// context and shuts them down in order on SIGINT or SIGTERM.
+package rungroup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Group is a set of goroutines sharing a context that is cancelled when the
+// process receives SIGINT or SIGTERM, or when any goroutine returns.
+type Group struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	err     error
+	closers []func(context.Context) error
+}
+
+// New returns a Group whose shutdown functions are given timeout to finish.
+func New(timeout time.Duration) *Group {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	return &Group{ctx: ctx, cancel: stop, timeout: timeout}
+}
+
+// WithContext is like New but shuts down when ctx is done instead of on
+// signals, for running a command's components in-process.
+func WithContext(ctx context.Context, timeout time.Duration) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{ctx: ctx, cancel: cancel, timeout: timeout}
+}
+
+// Go runs fn in a new goroutine. When fn returns the whole Group begins
+// shutting down; the first non-nil error is returned from Wait.
+func (g *Group) Go(fn func(context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.cancel()
+		if err := fn(g.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			g.setErr(err)
+		}
+	}()
+}
+
+// Defer registers fn to run once the Group's context is cancelled. Like
+// defer, functions run in the reverse order of registration, so register a
+// resource before the things that depend on it.
+func (g *Group) Defer(fn func(context.Context) error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closers = append(g.closers, fn)
+}
+
+// DeferClose registers c to be closed on shutdown.
+func (g *Group) DeferClose(c io.Closer) {
+	g.Defer(func(context.Context) error { return c.Close() })
+}
+
+// Serve serves srv on ln until shutdown, when srv is gracefully shut down.
+func (g *Group) Serve(srv *http.Server, ln net.Listener) {
+	g.Defer(srv.Shutdown)
+	g.Go(func(context.Context) error {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+}
+
+// Wait blocks until the Group's context is cancelled, runs the deferred
+// shutdown functions and waits for every goroutine to return. Goroutines
+// still running once the timeout passed to New expires are abandoned.
+func (g *Group) Wait() error {
+	<-g.ctx.Done()
+	log.Printf("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+	g.mu.Lock()
+	closers := g.closers
+	g.mu.Unlock()
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i](ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("shutdown: timed out waiting for goroutines to exit")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+func (g *Group) setErr(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err == nil {
+		g.err = err
+	}
+}
@@ -0,0 +1,195 @@
+// Package scrape runs periodic collection loops. A command supplies a
+// Collect function per target; scheduling, jitter, retries, per-target
+// metrics and readiness are handled here.
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/patrickod/pcmds/internal/health"
+	"github.com/patrickod/pcmds/internal/rungroup"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A Target is one thing collected on a schedule.
+type Target struct {
+	// Name identifies the target in logs and in the "target" metric label.
+	Name string
+	// Interval is the time between collections.
+	Interval time.Duration
+	// Jitter randomly shifts each collection by up to ±Jitter so that
+	// targets sharing an upstream don't fire in lockstep. Default
+	// Interval/10.
+	Jitter time.Duration
+	// Timeout bounds a single Collect attempt. Default Interval.
+	Timeout time.Duration
+	// Retries is the number of extra attempts after Collect fails.
+	Retries int
+	// Collect gathers the target's data and updates its metrics.
+	Collect func(ctx context.Context) error
+
+	lastSuccess health.Timestamp
+	// progress is the Unix nanosecond time the target's loop last started
+	// or finished a collection.
+	progress atomic.Int64
+}
+
+// Scraper schedules a set of targets.
+type Scraper struct {
+	targets []*Target
+
+	duration    *prometheus.HistogramVec
+	success     *prometheus.GaugeVec
+	lastSuccess *prometheus.GaugeVec
+	failures    *prometheus.CounterVec
+}
+
+// New returns a Scraper whose per-target metrics are registered on reg.
+func New(reg prometheus.Registerer) *Scraper {
+	s := &Scraper{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pcmds_collect_duration_seconds",
+			Help:    "Time taken to collect a target, including retries",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+		}, []string{"target"}),
+		success: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pcmds_collect_success",
+			Help: "Whether the last collection of the target succeeded",
+		}, []string{"target"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pcmds_collect_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful collection of the target",
+		}, []string{"target"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pcmds_collect_failures_total",
+			Help: "Number of failed collection attempts of the target",
+		}, []string{"target"}),
+	}
+	reg.MustRegister(s.duration, s.success, s.lastSuccess, s.failures)
+	return s
+}
+
+// Add registers a target. It must be called before Start. It fails if the
+// target's schedule is invalid, such as a non-positive Interval from config.
+func (s *Scraper) Add(t *Target) error {
+	switch {
+	case t.Interval <= 0:
+		return fmt.Errorf("scrape: %s: interval must be positive, got %s", t.Name, t.Interval)
+	case t.Jitter < 0 || t.Jitter >= t.Interval:
+		return fmt.Errorf("scrape: %s: jitter %s must be in [0, interval)", t.Name, t.Jitter)
+	case t.Timeout < 0:
+		return fmt.Errorf("scrape: %s: negative timeout %s", t.Name, t.Timeout)
+	case t.Retries < 0:
+		return fmt.Errorf("scrape: %s: negative retries %d", t.Name, t.Retries)
+	}
+	if t.Jitter == 0 {
+		t.Jitter = t.Interval / 10
+	}
+	if t.Timeout == 0 {
+		t.Timeout = t.Interval
+	}
+	s.targets = append(s.targets, t)
+	return nil
+}
+
+// Start runs every target in g: once immediately, then every Interval
+// until g shuts down.
+func (s *Scraper) Start(g *rungroup.Group) {
+	for _, t := range s.targets {
+		t.progress.Store(time.Now().UnixNano())
+		g.Go(func(ctx context.Context) error {
+			s.loop(ctx, t)
+			return nil
+		})
+	}
+}
+
+// AddReadiness registers a readiness check per target on h that fails once
+// the target hasn't been collected successfully for three intervals.
+func (s *Scraper) AddReadiness(h *health.Health) {
+	for _, t := range s.targets {
+		h.AddReadiness(t.Name, t.lastSuccess.Fresh(3*t.Interval))
+	}
+}
+
+// AddLiveness registers a liveness check per target on h that fails once
+// the target's loop has gone twice as long as a full collection cycle can
+// take without finishing a collection, such as when Collect hangs and
+// ignores its context.
+func (s *Scraper) AddLiveness(h *health.Health) {
+	for _, t := range s.targets {
+		stall := 2 * t.cycle()
+		h.AddLiveness(t.Name, func(context.Context) error {
+			if age := time.Since(time.Unix(0, t.progress.Load())); age > stall {
+				return fmt.Errorf("no collection finished in %s", age.Round(time.Second))
+			}
+			return nil
+		})
+	}
+}
+
+// cycle is the longest a healthy loop iteration can take: the wait between
+// collections plus every attempt and the backoff between them.
+func (t *Target) cycle() time.Duration {
+	d := t.Interval + t.Jitter + time.Duration(t.Retries+1)*t.Timeout
+	for attempt := 0; attempt < t.Retries; attempt++ {
+		d += time.Second << attempt
+	}
+	return d
+}
+
+func (s *Scraper) loop(ctx context.Context, t *Target) {
+	for {
+		s.collect(ctx, t)
+		t.progress.Store(time.Now().UnixNano())
+
+		next := t.Interval
+		if t.Jitter > 0 {
+			next += rand.N(2*t.Jitter) - t.Jitter
+		}
+		timer := time.NewTimer(next)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scraper) collect(ctx context.Context, t *Target) {
+	start := time.Now()
+	defer func() {
+		s.duration.WithLabelValues(t.Name).Observe(time.Since(start).Seconds())
+	}()
+
+	for attempt := 0; ; attempt++ {
+		actx, cancel := context.WithTimeout(ctx, t.Timeout)
+		err := t.Collect(actx)
+		cancel()
+		if err == nil {
+			t.lastSuccess.Mark()
+			s.success.WithLabelValues(t.Name).Set(1)
+			s.lastSuccess.WithLabelValues(t.Name).SetToCurrentTime()
+			return
+		}
+
+		log.Printf("collecting %s: %v", t.Name, err)
+		s.failures.WithLabelValues(t.Name).Inc()
+		if attempt >= t.Retries || ctx.Err() != nil {
+			s.success.WithLabelValues(t.Name).Set(0)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second << attempt):
+		}
+	}
+}
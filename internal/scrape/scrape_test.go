@@ -0,0 +1,81 @@
+package scrape
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/patrickod/pcmds/internal/health"
+	"github.com/patrickod/pcmds/internal/rungroup"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLivenessStall(t *testing.T) {
+	hang := make(chan struct{})
+	s := New(prometheus.NewRegistry())
+	err := s.Add(&Target{
+		Name:     "hung",
+		Interval: 10 * time.Millisecond,
+		Collect: func(context.Context) error {
+			<-hang // ignores its context, like colly's Visit
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := health.New()
+	s.AddLiveness(h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g := rungroup.WithContext(ctx, time.Second)
+	s.Start(g)
+	defer func() {
+		close(hang)
+		cancel()
+		g.Wait()
+	}()
+
+	if err := h.Live(ctx); err != nil {
+		t.Fatalf("Live at start: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for h.Live(ctx) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Live still passing with Collect hung")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAddValidates(t *testing.T) {
+	tests := []struct {
+		name                      string
+		interval, jitter, timeout time.Duration
+		retries                   int
+		ok                        bool
+	}{
+		{name: "defaults", interval: time.Minute, ok: true},
+		{name: "zero interval"},
+		{name: "negative interval", interval: -time.Second},
+		{name: "negative jitter", interval: time.Minute, jitter: -time.Second},
+		{name: "jitter not below interval", interval: time.Minute, jitter: time.Minute},
+		{name: "negative timeout", interval: time.Minute, timeout: -time.Second},
+		{name: "negative retries", interval: time.Minute, retries: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(prometheus.NewRegistry()).Add(&Target{
+				Name:     tt.name,
+				Interval: tt.interval,
+				Jitter:   tt.jitter,
+				Timeout:  tt.timeout,
+				Retries:  tt.retries,
+				Collect:  func(context.Context) error { return nil },
+			})
+			if (err == nil) != tt.ok {
+				t.Errorf("Add = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
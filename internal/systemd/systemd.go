@@ -0,0 +1,104 @@
+// Package systemd implements sd_notify readiness, status and watchdog
+// support. Outside of a Type=notify systemd unit every method is a no-op.
+package systemd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mdlayher/sdnotify"
+)
+
+// Notifier reports service state to systemd.
+type Notifier struct {
+	n *sdnotify.Notifier
+}
+
+// New returns a Notifier for $NOTIFY_SOCKET.
+func New() *Notifier {
+	n, err := sdnotify.New()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("systemd: %v", err)
+	}
+	return &Notifier{n: n}
+}
+
+func (n *Notifier) notify(s ...string) {
+	if err := n.n.Notify(s...); err != nil {
+		log.Printf("systemd: notify: %v", err)
+	}
+}
+
+// Ready tells systemd the service has finished starting.
+func (n *Notifier) Ready() {
+	n.notify(sdnotify.Ready)
+}
+
+// Stopping tells systemd the service is shutting down.
+func (n *Notifier) Stopping() {
+	n.notify(sdnotify.Stopping)
+}
+
+// Status sets the one-line status shown by systemctl status.
+func (n *Notifier) Status(format string, args ...any) {
+	n.notify(sdnotify.Statusf(format, args...))
+}
+
+// Close closes the notification socket.
+func (n *Notifier) Close() error {
+	return n.n.Close()
+}
+
+// watchdogInterval returns the watchdog timeout systemd configured for this
+// process, or 0 if there is none.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog pings the systemd watchdog at half the configured WatchdogSec
+// for as long as alive passes, until ctx is done. If alive fails, or the
+// process hangs, the pings stop and systemd restarts the service; the
+// failure is shown in systemctl status in the meantime. If no watchdog is
+// configured Watchdog just waits for ctx.
+func (n *Notifier) Watchdog(ctx context.Context, alive func(context.Context) error) {
+	interval := watchdogInterval()
+	if n.n == nil || interval == 0 {
+		<-ctx.Done()
+		return
+	}
+	t := time.NewTicker(interval / 2)
+	defer t.Stop()
+	var failing bool
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		cctx, cancel := context.WithTimeout(ctx, interval/2)
+		err := alive(cctx)
+		cancel()
+		if err != nil {
+			log.Printf("systemd: withholding watchdog ping: %v", err)
+			n.Status("withholding watchdog ping: %v", err)
+			failing = true
+			continue
+		}
+		if failing {
+			n.Status("")
+			failing = false
+		}
+		n.notify("WATCHDOG=1")
+	}
+}
@@ -0,0 +1,113 @@
+package tsnetutil
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/patrickod/pcmds/internal/health"
+	"tailscale.com/ipn"
+	"tailscale.com/tsnet"
+)
+
+// credentialName is the systemd credential (LoadCredential=) checked for an
+// auth key when -tsnet-authkey-file isn't set.
+const credentialName = "ts-authkey"
+
+// authKey returns the auth key from, in order, -tsnet-authkey-file, the
+// ts-authkey systemd credential and $TS_AUTHKEY. An empty key is fine for a
+// node that is already logged in.
+func (c *Config) authKey() (string, error) {
+	path := c.AuthKeyFile
+	if path == "" {
+		if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+			cred := filepath.Join(dir, credentialName)
+			if _, err := os.Stat(cred); err == nil {
+				path = cred
+			}
+		}
+	}
+	if path == "" {
+		return os.Getenv("TS_AUTHKEY"), nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading auth key: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// HealthCheck returns a readiness check that fails while srv is not logged
+// in to the tailnet, such as after its node key expires. The error includes
+// the URL to re-authenticate the node at, when there is one.
+func HealthCheck(srv *tsnet.Server) health.Checker {
+	return func(ctx context.Context) error {
+		lc, err := srv.LocalClient()
+		if err != nil {
+			return err
+		}
+		st, err := lc.StatusWithoutPeers(ctx)
+		if err != nil {
+			return err
+		}
+		if st.Self != nil && st.Self.KeyExpiry != nil && st.Self.KeyExpiry.Before(time.Now()) {
+			return fmt.Errorf("node key expired at %s", st.Self.KeyExpiry.Format(time.RFC3339))
+		}
+		if st.BackendState != ipn.Running.String() {
+			if st.AuthURL != "" {
+				return fmt.Errorf("%s, log in at %s", st.BackendState, st.AuthURL)
+			}
+			return fmt.Errorf("%s", st.BackendState)
+		}
+		return nil
+	}
+}
+
+// WatchAuth logs srv's state changes and any interactive login URL until ctx
+// is done, so a node that needs re-authenticating says so in the logs rather
+// than silently dropping off the tailnet.
+func WatchAuth(ctx context.Context, srv *tsnet.Server) {
+	for ctx.Err() == nil {
+		if err := watchAuth(ctx, srv); err != nil && ctx.Err() == nil {
+			log.Printf("tsnet: watching state: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}
+}
+
+func watchAuth(ctx context.Context, srv *tsnet.Server) error {
+	lc, err := srv.LocalClient()
+	if err != nil {
+		return err
+	}
+	w, err := lc.WatchIPNBus(ctx, ipn.NotifyInitialState)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for {
+		n, err := w.Next()
+		if err != nil {
+			return err
+		}
+		if n.State != nil {
+			log.Printf("tsnet: state %s", *n.State)
+			if *n.State == ipn.NeedsLogin && srv.AuthKey == "" {
+				log.Printf("tsnet: node needs login; set -tsnet-authkey-file, the %s credential or $TS_AUTHKEY and restart, or use the login URL", credentialName)
+			}
+		}
+		if n.BrowseToURL != nil && *n.BrowseToURL != "" {
+			log.Printf("tsnet: to authenticate, visit %s", *n.BrowseToURL)
+		}
+		if n.ErrMessage != nil {
+			log.Printf("tsnet: %s", *n.ErrMessage)
+		}
+	}
+}
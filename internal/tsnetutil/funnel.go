@@ -0,0 +1,66 @@
+package tsnetutil
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"tailscale.com/tsnet"
+)
+
+// HTTPS reports whether c serves HTTPS rather than plain HTTP.
+func (c *Config) HTTPS() bool {
+	return c.Enabled && (c.TLS || c.Funnel)
+}
+
+func (c *Config) listenFunnel(srv *tsnet.Server) (net.Listener, error) {
+	var opts []tsnet.FunnelOption
+	if c.FunnelOnly {
+		opts = append(opts, tsnet.FunnelOnly())
+	}
+	ln, err := srv.ListenFunnel("tcp", ":443", opts...)
+	if err != nil {
+		return nil, err
+	}
+	if domains := srv.CertDomains(); len(domains) > 0 {
+		log.Printf("funnel enabled: https://%s/", domains[0])
+	}
+	return ln, nil
+}
+
+// ListenHTTPRedirect listens on the tailnet's :80 and returns a handler that
+// redirects every request there to HTTPS. It returns a nil listener when
+// -tsnet-http-redirect is unset or c doesn't serve HTTPS.
+//
+// The redirect is tailnet-only: Funnel can't expose port 80.
+func (c *Config) ListenHTTPRedirect(srv *tsnet.Server) (net.Listener, http.Handler, error) {
+	if !c.HTTPRedirect || !c.HTTPS() || srv == nil {
+		return nil, nil, nil
+	}
+	ln, err := srv.Listen("tcp", ":80")
+	if err != nil {
+		return nil, nil, err
+	}
+	var host string
+	if domains := srv.CertDomains(); len(domains) > 0 {
+		host = domains[0]
+	}
+	return ln, HTTPSRedirect(host), nil
+}
+
+// HTTPSRedirect returns a handler that permanently redirects requests to
+// the same path on https://host. If host is empty the request's own host is
+// used.
+func HTTPSRedirect(host string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := host
+		if h == "" {
+			h = r.Host
+			if i := strings.LastIndexByte(h, ':'); i > strings.LastIndexByte(h, ']') {
+				h = h[:i]
+			}
+		}
+		http.Redirect(w, r, "https://"+h+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,108 @@
+// Package tsnetutil configures a tsnet node and its listener from a common
+// set of -tsnet flags.
+package tsnetutil
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net"
+
+	"tailscale.com/tsnet"
+)
+
+// Config holds the tsnet options for a command.
+type Config struct {
+	Enabled     bool   `yaml:"enabled" env:"TSNET"`
+	Hostname    string `yaml:"hostname" env:"TSNET_HOSTNAME"`
+	Dir         string `yaml:"dir" env:"TSNET_DIR"`
+	AuthKeyFile string `yaml:"authkey_file" env:"TSNET_AUTHKEY_FILE"`
+	Ephemeral   bool   `yaml:"ephemeral" env:"TSNET_EPHEMERAL"`
+	TLS         bool   `yaml:"tls" env:"TSNET_TLS"`
+	Funnel      bool   `yaml:"funnel" env:"TSNET_FUNNEL"`
+	FunnelOnly  bool   `yaml:"funnel_only" env:"TSNET_FUNNEL_ONLY"`
+
+	HTTPRedirect bool `yaml:"http_redirect" env:"TSNET_HTTP_REDIRECT"`
+}
+
+// RegisterFlags registers the tsnet flags on fs, bound to c. The current
+// values of c are used as the flag defaults, so set c.Hostname to the
+// command's default node name first.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.Enabled, "tsnet", c.Enabled, "run as a tsnet service")
+	fs.StringVar(&c.Hostname, "tsnet-hostname", c.Hostname, "tailnet hostname of the tsnet node")
+	fs.StringVar(&c.Dir, "tsnet-dir", c.Dir, "tsnet state directory (default: tsnet's per-program config dir)")
+	fs.StringVar(&c.AuthKeyFile, "tsnet-authkey-file", c.AuthKeyFile, "file containing the Tailscale auth key (default: systemd credential "+credentialName+", then $TS_AUTHKEY)")
+	fs.BoolVar(&c.Ephemeral, "tsnet-ephemeral", c.Ephemeral, "register the tsnet node as ephemeral")
+	fs.BoolVar(&c.TLS, "tsnet-tls", c.TLS, "serve HTTPS on :443 using the node's tailnet certificate")
+	fs.BoolVar(&c.Funnel, "tsnet-funnel", c.Funnel, "serve on :443 via Tailscale Funnel (implies -tsnet-tls)")
+	fs.BoolVar(&c.FunnelOnly, "tsnet-funnel-only", c.FunnelOnly, "with -tsnet-funnel, accept only public Funnel traffic and not tailnet traffic")
+	fs.BoolVar(&c.HTTPRedirect, "tsnet-http-redirect", c.HTTPRedirect, "with -tsnet-tls or -tsnet-funnel, also listen on tailnet :80 and redirect to HTTPS")
+}
+
+// Validate rejects flag combinations that would otherwise be silently
+// ignored, such as -tsnet-funnel-only without -tsnet-funnel.
+func (c *Config) Validate() error {
+	switch {
+	case (c.TLS || c.Funnel || c.HTTPRedirect) && !c.Enabled:
+		return errors.New("-tsnet-tls, -tsnet-funnel and -tsnet-http-redirect require -tsnet")
+	case c.FunnelOnly && !c.Funnel:
+		return errors.New("-tsnet-funnel-only requires -tsnet-funnel")
+	case c.HTTPRedirect && !c.HTTPS():
+		return errors.New("-tsnet-http-redirect requires -tsnet-tls or -tsnet-funnel")
+	}
+	return nil
+}
+
+// NewServer returns an unstarted tsnet.Server configured from c.
+func (c *Config) NewServer() (*tsnet.Server, error) {
+	authKey, err := c.authKey()
+	if err != nil {
+		return nil, err
+	}
+	return &tsnet.Server{
+		Hostname:  c.Hostname,
+		Dir:       c.Dir,
+		AuthKey:   authKey,
+		Ephemeral: c.Ephemeral,
+		Logf:      log.Printf,
+	}, nil
+}
+
+// Listen returns the listener the command should serve on. When tsnet is
+// disabled it listens on localAddr and the returned server is nil; otherwise
+// the caller owns the returned server and should Close it on exit. Invalid
+// flag combinations are reported before anything listens.
+func (c *Config) Listen(localAddr string) (net.Listener, *tsnet.Server, error) {
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if !c.Enabled {
+		ln, err := net.Listen("tcp", localAddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("listening on %s", ln.Addr().String())
+		return ln, nil, nil
+	}
+
+	srv, err := c.NewServer()
+	if err != nil {
+		return nil, nil, err
+	}
+	var ln net.Listener
+	switch {
+	case c.Funnel:
+		ln, err = c.listenFunnel(srv)
+	case c.TLS:
+		ln, err = srv.ListenTLS("tcp", ":443")
+	default:
+		ln, err = srv.Listen("tcp", ":80")
+	}
+	if err != nil {
+		srv.Close()
+		return nil, nil, err
+	}
+	log.Printf("listening on %s as %s", ln.Addr().String(), c.Hostname)
+	return ln, srv, nil
+}
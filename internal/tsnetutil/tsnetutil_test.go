@@ -0,0 +1,36 @@
+package tsnetutil
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       Config
+		wantErr bool
+	}{
+		{"disabled", Config{}, false},
+		{"plain", Config{Enabled: true}, false},
+		{"tls redirect", Config{Enabled: true, TLS: true, HTTPRedirect: true}, false},
+		{"funnel only", Config{Enabled: true, Funnel: true, FunnelOnly: true, HTTPRedirect: true}, false},
+		{"funnel only without funnel", Config{Enabled: true, TLS: true, FunnelOnly: true}, true},
+		{"redirect without https", Config{Enabled: true, HTTPRedirect: true}, true},
+		{"redirect without tsnet", Config{TLS: true, HTTPRedirect: true}, true},
+		{"tls without tsnet", Config{TLS: true}, true},
+		{"funnel without tsnet", Config{Funnel: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListenValidates(t *testing.T) {
+	c := Config{FunnelOnly: true}
+	if ln, _, err := c.Listen("127.0.0.1:0"); err == nil {
+		ln.Close()
+		t.Error("Listen accepted -tsnet-funnel-only without -tsnet-funnel")
+	}
+}
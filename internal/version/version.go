@@ -0,0 +1,108 @@
+// Package version reports which build of pcmds is running.
+//
+// The values come from the Go toolchain's embedded build information and can
+// be overridden at link time, e.g.
+//
+//	go build -ldflags "-X github.com/patrickod/pcmds/internal/version.Version=v1.2.3"
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Link-time overrides. Empty values are filled in from debug.ReadBuildInfo.
+var (
+	Version   string
+	Commit    string
+	BuildTime string
+)
+
+// Info describes the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+	return info
+}
+
+func (i Info) String() string {
+	commit := i.Commit
+	if commit == "" {
+		commit = "unknown"
+	} else if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if i.Modified {
+		commit += "-dirty"
+	}
+	s := fmt.Sprintf("%s (commit %s, %s", i.Version, commit, i.GoVersion)
+	if i.BuildTime != "" {
+		s += ", built " + i.BuildTime
+	}
+	return s + ")"
+}
+
+// Handler serves the build information as JSON, for mounting at /version.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Get())
+	})
+}
+
+// RegisterMetric registers a pcmds_build_info gauge, always 1, labelled with
+// the build information.
+func RegisterMetric(reg prometheus.Registerer) {
+	info := Get()
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pcmds_build_info",
+		Help: "Build information of the running pcmds binary",
+		ConstLabels: prometheus.Labels{
+			"version":    info.Version,
+			"commit":     info.Commit,
+			"go_version": info.GoVersion,
+		},
+	})
+	g.Set(1)
+	reg.MustRegister(g)
+}